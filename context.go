@@ -0,0 +1,205 @@
+package godocker
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// loadContext resolves the build context source for config, preferring an
+// inline Dockerfile, then an explicit WithContextURL, then falling back to
+// the local directory set with WithCwd.
+func loadContext(config Config) (body io.Reader, err error) {
+	if config.DockerfileInline != nil {
+		return singleFileTar("Dockerfile", config.DockerfileInline)
+	}
+	dockerfilePath := ""
+	if config.ImageBuildOpts != nil {
+		dockerfilePath = config.ImageBuildOpts.Dockerfile
+	}
+	if config.ContextURL != "" {
+		return loadContextURL(config.ContextURL, dockerfilePath)
+	}
+	return NewTarDirReader(config.Cwd, WithDockerfilePath(dockerfilePath)), nil
+}
+
+// loadContextURL resolves a WithContextURL value into a tar stream,
+// mirroring the context sources `docker build` itself accepts. dockerfilePath
+// is the context-relative Dockerfile path (from WithDockerfile), kept in the
+// context even if an ignore pattern would otherwise exclude it.
+func loadContextURL(raw, dockerfilePath string) (io.Reader, error) {
+	if raw == "-" {
+		return os.Stdin, nil
+	}
+	urlWithoutFragment := raw
+	if idx := strings.Index(urlWithoutFragment, "#"); idx != -1 {
+		urlWithoutFragment = urlWithoutFragment[:idx]
+	}
+	switch {
+	case strings.HasPrefix(raw, "http://"), strings.HasPrefix(raw, "https://"):
+		return httpContext(raw)
+	case strings.HasPrefix(raw, "git://"), strings.HasSuffix(urlWithoutFragment, ".git"):
+		return gitContext(raw, dockerfilePath)
+	default:
+		// shorthand git ref, e.g. github.com/user/repo#ref:subdir
+		return gitContext(raw, dockerfilePath)
+	}
+}
+
+// gitContext shallow-clones the repo named by raw (optionally pinned to a
+// ref and scoped to a subdir via a `#ref:subdir` fragment) and tars the
+// result with NewTarDirReader.
+func gitContext(raw, dockerfilePath string) (io.Reader, error) {
+	repoURL, ref, subdir := parseGitRef(raw)
+
+	dir, err := os.MkdirTemp("", "godocker-context-")
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"clone", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref, "--single-branch")
+	}
+	args = append(args, repoURL, dir)
+
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("git clone %s: %w", repoURL, err)
+	}
+
+	root := dir
+	if subdir != "" {
+		root = filepath.Join(dir, subdir)
+	}
+	return &cleanupOnEOFReader{
+		Reader:  NewTarDirReader(root, WithDockerfilePath(dockerfilePath)),
+		cleanup: func() { os.RemoveAll(dir) },
+	}, nil
+}
+
+// scpLikeGitURL matches scp-style git remotes such as
+// `git@github.com:user/repo.git`, which git clones directly over ssh and
+// which must not be rewritten into an https:// URL.
+var scpLikeGitURL = regexp.MustCompile(`^[\w.-]+@[\w.-]+:.+$`)
+
+// parseGitRef splits a `git://…`, `https://…`, `user@host:path` (scp-style),
+// or `github.com/user/repo` reference into a cloneable repo URL plus an
+// optional `#ref:subdir` fragment, matching Docker's own git context syntax.
+func parseGitRef(raw string) (repoURL, ref, subdir string) {
+	repoURL = raw
+	frag := ""
+	if idx := strings.Index(raw, "#"); idx != -1 {
+		repoURL = raw[:idx]
+		frag = raw[idx+1:]
+	}
+	if frag != "" {
+		if idx := strings.Index(frag, ":"); idx != -1 {
+			ref, subdir = frag[:idx], frag[idx+1:]
+		} else {
+			ref = frag
+		}
+	}
+	if !strings.Contains(repoURL, "://") && !scpLikeGitURL.MatchString(repoURL) {
+		repoURL = "https://" + repoURL
+	}
+	return
+}
+
+// httpContext fetches raw, transparently gunzipping it, and returns it
+// unchanged if it is already a tarball or wraps it as a single-entry tar if
+// it is a bare Dockerfile response.
+func httpContext(raw string) (io.Reader, error) {
+	resp, err := http.Get(raw)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching build context from %s: unexpected status %s", raw, resp.Status)
+	}
+
+	var body io.Reader = bufio.NewReader(resp.Body)
+	if magic, err := body.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		if body, err = gzip.NewReader(body); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+
+	head, err := io.ReadAll(io.LimitReader(body, 512))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	rest := io.MultiReader(bytes.NewReader(head), body)
+
+	if isTar(head) {
+		return &cleanupOnEOFReader{Reader: rest, cleanup: func() { resp.Body.Close() }}, nil
+	}
+
+	dockerfile, err := io.ReadAll(rest)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return singleFileTar("Dockerfile", dockerfile)
+}
+
+// isTar reports whether the start of a stream looks like a POSIX tar
+// archive, i.e. it carries the "ustar" magic at offset 257.
+func isTar(head []byte) bool {
+	return len(head) >= 262 && string(head[257:262]) == "ustar"
+}
+
+// cleanupOnEOFReader runs cleanup exactly once, after Reader reports an
+// error (including io.EOF), so callers that only read a context tar to
+// completion don't need to separately track resources backing it, such as
+// an http.Response body or a git clone's temp directory.
+type cleanupOnEOFReader struct {
+	io.Reader
+	cleanup func()
+	done    bool
+}
+
+func (r *cleanupOnEOFReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	if err != nil && !r.done {
+		r.done = true
+		r.cleanup()
+	}
+	return
+}
+
+// singleFileTar builds a one-entry tar archive containing name/contents,
+// used for WithDockerfileInline and bare-Dockerfile HTTP contexts.
+func singleFileTar(name string, contents []byte) (io.Reader, error) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: int64(len(contents)),
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(contents); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
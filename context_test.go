@@ -0,0 +1,90 @@
+package godocker
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseGitRef(t *testing.T) {
+	cases := []struct {
+		name        string
+		raw         string
+		wantRepoURL string
+		wantRef     string
+		wantSubdir  string
+	}{
+		{
+			name:        "shorthand gets an https scheme",
+			raw:         "github.com/user/repo",
+			wantRepoURL: "https://github.com/user/repo",
+		},
+		{
+			name:        "shorthand with ref and subdir",
+			raw:         "github.com/user/repo#main:cmd/app",
+			wantRepoURL: "https://github.com/user/repo",
+			wantRef:     "main",
+			wantSubdir:  "cmd/app",
+		},
+		{
+			name:        "shorthand with ref only",
+			raw:         "github.com/user/repo#v1.2.3",
+			wantRepoURL: "https://github.com/user/repo",
+			wantRef:     "v1.2.3",
+		},
+		{
+			name:        "explicit https is left untouched",
+			raw:         "https://example.com/user/repo.git",
+			wantRepoURL: "https://example.com/user/repo.git",
+		},
+		{
+			name:        "git protocol is left untouched",
+			raw:         "git://example.com/repo.git#main",
+			wantRepoURL: "git://example.com/repo.git",
+			wantRef:     "main",
+		},
+		{
+			name:        "scp-style remote is left untouched",
+			raw:         "git@github.com:user/repo.git",
+			wantRepoURL: "git@github.com:user/repo.git",
+		},
+		{
+			name:        "scp-style remote with a ref and subdir fragment",
+			raw:         "git@github.com:user/repo.git#main:sub",
+			wantRepoURL: "git@github.com:user/repo.git",
+			wantRef:     "main",
+			wantSubdir:  "sub",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repoURL, ref, subdir := parseGitRef(c.raw)
+			if repoURL != c.wantRepoURL || ref != c.wantRef || subdir != c.wantSubdir {
+				t.Errorf("parseGitRef(%q) = (%q, %q, %q), want (%q, %q, %q)",
+					c.raw, repoURL, ref, subdir, c.wantRepoURL, c.wantRef, c.wantSubdir)
+			}
+		})
+	}
+}
+
+func TestIsTar(t *testing.T) {
+	validHeader := make([]byte, 300)
+	copy(validHeader[257:], "ustar")
+
+	cases := []struct {
+		name string
+		head []byte
+		want bool
+	}{
+		{"valid ustar header", validHeader, true},
+		{"too short to contain the magic", []byte("short"), false},
+		{"long enough but no magic present", make([]byte, 300), false},
+		{"magic at the wrong offset", append(bytes.Repeat([]byte{0}, 100), []byte("ustar")...), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isTar(c.head); got != c.want {
+				t.Errorf("isTar() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
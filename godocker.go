@@ -37,11 +37,36 @@ type Config struct {
 	Cli       *client.Client
 	Container *container.CreateResponse
 	ImageTags []string
+	ExitCode  int
+
+	ContextURL       string
+	DockerfileInline []byte
 
 	// internal
 	usingAPIVersion bool
-	execRunners     []func(config Config) (err error)
-	afterRunners    []func(config Config) (err error)
+	execRunners     []func(config *Config) (err error)
+	afterRunners    []func(config *Config) (err error)
+	progress        chan<- ProgressEvent
+	progressWriter  io.Writer
+}
+
+// Result is returned by Run once the container has been created and its
+// exec runners have completed.
+type Result struct {
+	ExitCode  int
+	Container string
+}
+
+// ExitError is returned by Run when the container's exit code is non-zero,
+// which WaitForExit (run implicitly unless other Exec runners are given)
+// observes via ContainerWait.
+type ExitError struct {
+	Code      int
+	Container string
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("container %s exited with code %d", e.Container, e.Code)
 }
 
 func WithClientOpts(opts ...client.Opt) Opt {
@@ -73,20 +98,83 @@ func WithCwd(cwd string) Opt {
 	}
 }
 
-func Exec(runners ...func(config Config) (err error)) Opt {
+// WithContextURL sets the build context to something other than a local
+// directory: an `http(s)://…` tarball or Dockerfile, a `git://…` or
+// `github.com/user/repo#ref:subdir` remote, or `-` to read a tar from
+// stdin. It is ignored if WithDockerfileInline is also set.
+func WithContextURL(url string) Opt {
+	return func(config Config) Config {
+		config.ContextURL = url
+		return config
+	}
+}
+
+// WithDockerfileInline builds from an in-memory Dockerfile with no
+// surrounding context, equivalent to `docker build -f - -` with contents
+// piped in.
+func WithDockerfileInline(contents []byte) Opt {
+	return func(config Config) Config {
+		config.DockerfileInline = contents
+		return config
+	}
+}
+
+// WithProgress routes build and pull progress events onto ch instead of
+// stdout. Events are sent non-blockingly, so a slow or unread channel drops
+// events rather than stalling the build/pull.
+func WithProgress(ch chan<- ProgressEvent) Opt {
+	return func(config Config) Config {
+		config.progress = ch
+		return config
+	}
+}
+
+// WithProgressWriter writes build and pull progress as it would appear in
+// `docker build`/`docker pull` output to w instead of stdout.
+func WithProgressWriter(w io.Writer) Opt {
+	return func(config Config) Config {
+		config.progressWriter = w
+		return config
+	}
+}
+
+func Exec(runners ...func(config *Config) (err error)) Opt {
 	return func(config Config) Config {
 		config.execRunners = append(config.execRunners, runners...)
 		return config
 	}
 }
 
-func After(runners ...func(config Config) (err error)) Opt {
+func After(runners ...func(config *Config) (err error)) Opt {
 	return func(config Config) Config {
 		config.afterRunners = append(config.afterRunners, runners...)
 		return config
 	}
 }
 
+// WaitForExit waits for the container to stop, records its exit code on
+// Config, and causes Run to return an *ExitError if it is non-zero. It runs
+// implicitly when Run is called with no other Exec runners, since a
+// container with nothing observing its exit would otherwise be stopped
+// before its workload finished.
+func WaitForExit() Opt {
+	return func(config Config) Config {
+		config.execRunners = append(config.execRunners, waitForExit)
+		return config
+	}
+}
+
+func waitForExit(config *Config) (err error) {
+	statusCh, errCh := config.Cli.ContainerWait(config.Ctx, config.Container.ID, container.WaitConditionNotRunning)
+	select {
+	case err = <-errCh:
+		return
+	case status := <-statusCh:
+		config.ExitCode = int(status.StatusCode)
+	}
+	return
+}
+
 func WithDockerfile(path string) Opt {
 	return func(config Config) Config {
 		if config.ImageBuildOpts == nil {
@@ -115,7 +203,7 @@ func WithConfig(config Config) Opt {
 
 func CleanupContainer() Opt {
 	return func(config Config) Config {
-		config.afterRunners = append(config.afterRunners, func(config Config) (err error) {
+		config.afterRunners = append(config.afterRunners, func(config *Config) (err error) {
 			fmt.Println("removing container")
 			if err = config.Cli.ContainerRemove(config.Ctx, config.Container.ID, types.ContainerRemoveOptions{RemoveVolumes: true}); err != nil {
 				return
@@ -128,7 +216,7 @@ func CleanupContainer() Opt {
 
 func CleanupImage() Opt {
 	return func(config Config) Config {
-		config.afterRunners = append(config.afterRunners, func(config Config) (err error) {
+		config.afterRunners = append(config.afterRunners, func(config *Config) (err error) {
 			opts := types.ImageListOptions{
 				Filters: filters.NewArgs(),
 			}
@@ -157,48 +245,111 @@ func CleanupImage() Opt {
 	}
 }
 
-func loadContext(config Config) (context io.Reader, err error) {
-	context = NewTarDirReader(config.Cwd)
-	return
+// ProgressEvent is a single line of build or pull progress reported by the
+// Docker daemon, normalized from its newline-delimited JSON wire format.
+type ProgressEvent struct {
+	Stream     string
+	Status     string
+	ID         string
+	Current    int64
+	Total      int64
+	Progress   string
+	AuxImageID string
+	Error      string
 }
 
 type streamLine struct {
 	Stream         string `json:"stream"`
 	Status         string `json:"status"`
 	ProgressDetail struct {
-		Current int `json:"current"`
-		Total   int `json:"total"`
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
 	} `json:"progressDetail"`
 	Progress string `json:"progress"`
 	Id       string `json:"id"`
 	Aux      struct {
 		ID string `json:"ID"`
 	} `json:"aux"`
+	Error string `json:"error"`
+}
+
+func (s streamLine) toProgressEvent() ProgressEvent {
+	return ProgressEvent{
+		Stream:     s.Stream,
+		Status:     s.Status,
+		ID:         s.Id,
+		Current:    s.ProgressDetail.Current,
+		Total:      s.ProgressDetail.Total,
+		Progress:   s.Progress,
+		AuxImageID: s.Aux.ID,
+		Error:      s.Error,
+	}
 }
 
-func (s streamLine) IsStream() bool {
-	return s.Stream != ""
+// progressSink builds the function that streamDockerJSON forwards events to,
+// based on whichever of WithProgress/WithProgressWriter the caller set. If
+// neither was set, it falls back to writing the formatProgressLine output
+// (build stream text and pull status lines) to stdout.
+func (config Config) progressSink() func(ProgressEvent) {
+	ch := config.progress
+	w := config.progressWriter
+	if ch == nil && w == nil {
+		w = os.Stdout
+	}
+	return func(ev ProgressEvent) {
+		if ch != nil {
+			select {
+			case ch <- ev:
+			default:
+			}
+		}
+		if w != nil {
+			if line := formatProgressLine(ev); line != "" {
+				fmt.Fprint(w, line)
+			}
+		}
+	}
 }
 
-func consumeDockerStream(reader io.ReadCloser, lines chan<- streamLine) (err error) {
+// formatProgressLine renders a ProgressEvent the way `docker build`/`docker
+// pull` would print it. Build events carry their text in Stream; pull events
+// have no Stream and instead carry a Status (optionally scoped to a layer ID
+// and paired with a Progress bar).
+func formatProgressLine(ev ProgressEvent) string {
+	switch {
+	case ev.Stream != "":
+		return ev.Stream
+	case ev.Status == "":
+		return ""
+	case ev.ID != "" && ev.Progress != "":
+		return fmt.Sprintf("%s: %s %s\n", ev.ID, ev.Status, ev.Progress)
+	case ev.ID != "":
+		return fmt.Sprintf("%s: %s\n", ev.ID, ev.Status)
+	default:
+		return ev.Status + "\n"
+	}
+}
+
+// streamDockerJSON parses the newline-delimited JSON produced by the Docker
+// daemon for build and pull requests, forwarding each line to sink as a
+// ProgressEvent.
+func streamDockerJSON(reader io.ReadCloser, sink func(ProgressEvent)) (err error) {
 	defer reader.Close()
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
 		if err = scanner.Err(); err != nil {
 			return
 		}
-		line := scanner.Bytes()
 		var s streamLine
-		if err = json.Unmarshal(line, &s); err != nil {
+		if err = json.Unmarshal(scanner.Bytes(), &s); err != nil {
 			return
 		}
-		if s.IsStream() {
-			fmt.Println(s.Stream)
-		} else {
-			lines <- s
+		ev := s.toProgressEvent()
+		sink(ev)
+		if ev.Error != "" {
+			return errors.New(ev.Error)
 		}
 	}
-	close(lines)
 	return
 }
 
@@ -217,23 +368,19 @@ func buildImage(config Config) (imageTag string, err error) {
 	config.ImageBuildOpts.Tags = append(config.ImageBuildOpts.Tags, config.ImageBuildOpts.BuildID)
 	config.ImageBuildOpts.BuildID = strings.ToLower(zrand.AlphaWord(6))
 	imageTag = config.ImageBuildOpts.BuildID
-	if config.ImageBuildOpts.Context == nil {
-		config.ImageBuildOpts.Context, err = loadContext(config)
+	buildContext := config.ImageBuildOpts.Context
+	if buildContext == nil {
+		buildContext, err = loadContext(config)
 		if err != nil {
 			return "", err
 		}
 	}
 
 	buildComplete := false
-	buildContext, err := loadContext(config)
-	if err != nil {
-		return
-	}
 	res, err := config.Cli.ImageBuild(config.Ctx, buildContext, *config.ImageBuildOpts)
 	if err != nil {
 		return
 	}
-	lines := make(chan streamLine)
 	defer func() {
 		if !buildComplete {
 			fmt.Println("build cancelled")
@@ -243,14 +390,18 @@ func buildImage(config Config) (imageTag string, err error) {
 		}
 	}()
 
-	// This closes both resources once they are fully consumed
-	go consumeDockerStream(res.Body, lines)
-
-	// Pull the image id out of the stream
-	for l := range lines {
-		if l.Aux.ID != "" {
-			imageTag = l.Aux.ID
+	sink := config.progressSink()
+	var auxImageID string
+	if err = streamDockerJSON(res.Body, func(ev ProgressEvent) {
+		if ev.AuxImageID != "" {
+			auxImageID = ev.AuxImageID
 		}
+		sink(ev)
+	}); err != nil {
+		return
+	}
+	if auxImageID != "" {
+		imageTag = auxImageID
 	}
 
 	buildComplete = true
@@ -269,12 +420,10 @@ func pullImage(config Config) (err error) {
 	if err != nil {
 		return
 	}
-	defer out.Close()
-	_, err = io.Copy(os.Stdout, out)
-	return
+	return streamDockerJSON(out, config.progressSink())
 }
 
-func Run(opts ...Opt) (err error) {
+func Run(opts ...Opt) (result Result, err error) {
 	var config Config
 	for _, opt := range opts {
 		config = opt(config)
@@ -296,16 +445,17 @@ func Run(opts ...Opt) (err error) {
 
 	defer func() {
 		for _, after := range config.afterRunners {
-			if err = after(config); err != nil {
-				return
+			if aerr := after(&config); aerr != nil && err == nil {
+				err = aerr
 			}
 		}
 	}()
 
 	if config.ImageBuildOpts != nil {
-		imageTag, err := buildImage(config)
+		var imageTag string
+		imageTag, err = buildImage(config)
 		if err != nil {
-			return err
+			return
 		}
 		if config.ContainerConfig == nil {
 			config.ContainerConfig = &container.Config{}
@@ -317,13 +467,18 @@ func Run(opts ...Opt) (err error) {
 			return
 		}
 	} else {
-		return fmt.Errorf("no image build or pull options provided")
+		err = fmt.Errorf("no image build or pull options provided")
+		return
 	}
 
 	if err = execContainer(&config); err != nil {
 		return
 	}
 
+	result = Result{ExitCode: config.ExitCode, Container: config.Container.ID}
+	if config.ExitCode != 0 {
+		err = &ExitError{Code: config.ExitCode, Container: config.Container.ID}
+	}
 	return
 }
 
@@ -348,8 +503,12 @@ func execContainer(config *Config) (err error) {
 		}
 	}()
 
-	for _, runner := range config.execRunners {
-		if err = runner(*config); err != nil {
+	runners := config.execRunners
+	if len(runners) == 0 {
+		runners = []func(config *Config) (err error){waitForExit}
+	}
+	for _, runner := range runners {
+		if err = runner(config); err != nil {
 			return
 		}
 	}
@@ -0,0 +1,92 @@
+package godocker
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/docker/docker/api/types"
+)
+
+// LogsOptions configures the Logs runner.
+type LogsOptions struct {
+	Stdout     io.Writer
+	Stderr     io.Writer
+	Follow     bool
+	Since      string
+	Until      string
+	Tail       string
+	Timestamps bool
+}
+
+// Logs is an Exec runner that streams the running container's logs to
+// opts.Stdout/opts.Stderr, demultiplexing the daemon's combined stream when
+// the container has no TTY.
+func Logs(opts LogsOptions) func(config *Config) (err error) {
+	return func(config *Config) (err error) {
+		out, err := config.Cli.ContainerLogs(config.Ctx, config.Container.ID, types.ContainerLogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Follow:     opts.Follow,
+			Since:      opts.Since,
+			Until:      opts.Until,
+			Tail:       opts.Tail,
+			Timestamps: opts.Timestamps,
+		})
+		if err != nil {
+			return
+		}
+		defer out.Close()
+
+		tty := config.ContainerConfig != nil && config.ContainerConfig.Tty
+		done := make(chan error, 1)
+		go func() {
+			done <- demuxLogs(out, opts, tty)
+		}()
+
+		select {
+		case <-config.Ctx.Done():
+			out.Close()
+			<-done
+			return config.Ctx.Err()
+		case err = <-done:
+			return
+		}
+	}
+}
+
+// demuxLogs copies a container log stream to opts.Stdout/opts.Stderr. When
+// tty is true the daemon sends raw bytes with no framing, so they go
+// straight to Stdout. Otherwise the daemon multiplexes stdout and stderr
+// onto one stream using 8-byte frame headers: byte 0 is the stream index
+// (1=stdout, 2=stderr), bytes 1-3 are reserved, and bytes 4-7 are a
+// big-endian uint32 payload length.
+func demuxLogs(r io.Reader, opts LogsOptions, tty bool) (err error) {
+	if tty {
+		if opts.Stdout == nil {
+			return nil
+		}
+		_, err = io.Copy(opts.Stdout, r)
+		return
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err = io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		size := binary.BigEndian.Uint32(header[4:8])
+		w := opts.Stdout
+		if header[0] == 2 {
+			w = opts.Stderr
+		}
+		if w == nil {
+			w = io.Discard
+		}
+		if _, err = io.CopyN(w, r, int64(size)); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package godocker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func stdcopyFrame(streamType byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = streamType
+	binary.BigEndian.PutUint32(header[4:8], uint32(len(payload)))
+	return append(header, []byte(payload)...)
+}
+
+func TestDemuxLogsSplitsStdoutAndStderr(t *testing.T) {
+	var data []byte
+	data = append(data, stdcopyFrame(1, "hello stdout\n")...)
+	data = append(data, stdcopyFrame(2, "oops stderr\n")...)
+	data = append(data, stdcopyFrame(1, "more stdout\n")...)
+
+	var stdout, stderr bytes.Buffer
+	opts := LogsOptions{Stdout: &stdout, Stderr: &stderr}
+	if err := demuxLogs(bytes.NewReader(data), opts, false); err != nil {
+		t.Fatalf("demuxLogs() error = %v", err)
+	}
+	if got, want := stdout.String(), "hello stdout\nmore stdout\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+	if got, want := stderr.String(), "oops stderr\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+}
+
+func TestDemuxLogsTTYCopiesRawBytesToStdout(t *testing.T) {
+	var stdout bytes.Buffer
+	opts := LogsOptions{Stdout: &stdout}
+	if err := demuxLogs(bytes.NewReader([]byte("raw tty output, no framing")), opts, true); err != nil {
+		t.Fatalf("demuxLogs() error = %v", err)
+	}
+	if got, want := stdout.String(), "raw tty output, no framing"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+}
+
+func TestDemuxLogsTTYWithNilStdoutIsANoop(t *testing.T) {
+	if err := demuxLogs(bytes.NewReader([]byte("ignored")), LogsOptions{}, true); err != nil {
+		t.Fatalf("demuxLogs() error = %v, want nil", err)
+	}
+}
+
+func TestDemuxLogsDiscardsFramesWithNoMatchingWriter(t *testing.T) {
+	var stdout bytes.Buffer
+	data := stdcopyFrame(2, "stderr with no writer configured\n")
+	opts := LogsOptions{Stdout: &stdout}
+	if err := demuxLogs(bytes.NewReader(data), opts, false); err != nil {
+		t.Fatalf("demuxLogs() error = %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want empty", stdout.String())
+	}
+}
+
+func TestDemuxLogsTruncatedFrameHeaderReturnsError(t *testing.T) {
+	// Fewer than the 8 header bytes a real frame always has.
+	err := demuxLogs(bytes.NewReader([]byte{1, 0, 0}), LogsOptions{}, false)
+	if err == nil {
+		t.Fatal("demuxLogs() error = nil, want a non-nil error for a truncated frame header")
+	}
+}
+
+func TestDemuxLogsCleanEOFBetweenFramesIsNotAnError(t *testing.T) {
+	data := stdcopyFrame(1, "one full frame\n")
+	var stdout bytes.Buffer
+	opts := LogsOptions{Stdout: &stdout}
+	if err := demuxLogs(bytes.NewReader(data), opts, false); err != nil {
+		t.Fatalf("demuxLogs() error = %v, want nil", err)
+	}
+	if got, want := stdout.String(), "one full frame\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+}
@@ -2,27 +2,126 @@ package godocker
 
 import (
 	"archive/tar"
+	"bufio"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // Convert a directory into a readable tar stream
-func NewTarDirReader(dir string) *tarDirReader {
-	return &tarDirReader{
-		fs: os.DirFS(dir),
+func NewTarDirReader(dir string, opts ...TarDirOpt) *tarDirReader {
+	t := &tarDirReader{
+		dir: dir,
+		fs:  os.DirFS(dir),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// TarDirOpt configures a tarDirReader, e.g. to control .dockerignore handling.
+type TarDirOpt func(t *tarDirReader)
+
+// WithIgnoreFile loads ignore patterns from the file at path instead of the
+// default `.dockerignore` at the context root.
+func WithIgnoreFile(path string) TarDirOpt {
+	return func(t *tarDirReader) {
+		t.ignoreFile = path
+	}
+}
+
+// WithIgnorePatterns adds explicit ignore patterns on top of any loaded with
+// WithIgnoreFile, or instead of the default `.dockerignore` at the context
+// root, which is not loaded when explicit patterns are given.
+func WithIgnorePatterns(patterns ...string) TarDirOpt {
+	return func(t *tarDirReader) {
+		t.ignorePatterns = append(t.ignorePatterns, patterns...)
+	}
+}
+
+// WithDockerfilePath marks path (relative to the context root, e.g. what
+// WithDockerfile was given) as always included regardless of any ignore
+// pattern, matching `docker build`'s handling of a non-default `-f` path.
+// Defaults to "Dockerfile" when not set.
+func WithDockerfilePath(path string) TarDirOpt {
+	return func(t *tarDirReader) {
+		t.dockerfilePath = path
 	}
 }
 
 type tarDirReader struct {
-	fs          fs.FS
-	queue       []string
-	reader      *io.PipeReader
-	writer      *io.PipeWriter
-	archive     *tar.Writer
-	initialized bool
+	dir            string
+	fs             fs.FS
+	ignoreFile     string
+	ignorePatterns []string
+	dockerfilePath string
+	ignore         *ignoreMatcher
+	queue          []tarEntry
+	reader         *io.PipeReader
+	writer         *io.PipeWriter
+	archive        *tar.Writer
+	initialized    bool
+}
+
+// tarEntry is a single file, directory or symlink queued for writing to the
+// tar archive, keyed by its path relative to the context root.
+type tarEntry struct {
+	relPath string
+	absPath string
+	info    os.FileInfo
+}
+
+// alwaysKeepPaths are the context-relative paths that are included in the
+// build context regardless of any ignore pattern, matching `docker build`'s
+// behavior of never letting .dockerignore hide the Dockerfile it came from.
+func (t *tarDirReader) alwaysKeepPaths() map[string]bool {
+	dockerfilePath := t.dockerfilePath
+	if dockerfilePath == "" {
+		dockerfilePath = "Dockerfile"
+	}
+	dockerfilePath = filepath.ToSlash(filepath.Clean(dockerfilePath))
+	return map[string]bool{
+		dockerfilePath:  true,
+		".dockerignore": true,
+	}
+}
+
+func (t *tarDirReader) loadIgnorePatterns() (patterns []string, err error) {
+	patterns = append(patterns, t.ignorePatterns...)
+	if t.ignoreFile != "" {
+		data, err := os.ReadFile(t.ignoreFile)
+		if err != nil {
+			return nil, err
+		}
+		return append(patterns, parseIgnoreLines(data)...), nil
+	}
+	if len(t.ignorePatterns) > 0 {
+		return patterns, nil
+	}
+	data, err := fs.ReadFile(t.fs, ".dockerignore")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return patterns, nil
+		}
+		return nil, err
+	}
+	return append(patterns, parseIgnoreLines(data)...), nil
+}
+
+func parseIgnoreLines(data []byte) (patterns []string) {
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return
 }
 
 func (t *tarDirReader) init() (err error) {
@@ -32,14 +131,53 @@ func (t *tarDirReader) init() (err error) {
 	t.initialized = true
 	t.reader, t.writer = io.Pipe()
 	t.archive = tar.NewWriter(t.writer)
-	err = fs.WalkDir(t.fs, ".", func(path string, d fs.DirEntry, err error) error {
+
+	patterns, err := t.loadIgnorePatterns()
+	if err != nil {
+		return err
+	}
+	t.ignore = newIgnoreMatcher(patterns)
+	alwaysKeep := t.alwaysKeepPaths()
+
+	// filepath.Walk (rather than fs.WalkDir over an fs.FS) is used here so
+	// symlinks can be detected via Lstat and resolved with os.Readlink,
+	// which os.DirFS does not expose.
+	err = filepath.Walk(t.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(t.dir, path)
 		if err != nil {
 			return err
 		}
-		// TODO: some kind of filtering using .ignore files
-		if !d.IsDir() {
-			t.queue = append(t.queue, path)
+		relPath = filepath.ToSlash(relPath)
+		if relPath == "." {
+			return nil
+		}
+		if alwaysKeep[relPath] {
+			t.queue = append(t.queue, tarEntry{relPath: relPath, absPath: path, info: info})
+			return nil
 		}
+		excluded := t.ignore.Matches(relPath)
+		if info.IsDir() {
+			keepsInside := false
+			for keep := range alwaysKeep {
+				if strings.HasPrefix(keep, relPath+"/") {
+					keepsInside = true
+				}
+			}
+			if excluded && !keepsInside && !t.ignore.canMatchInside(relPath) {
+				return filepath.SkipDir
+			}
+			if !excluded {
+				t.queue = append(t.queue, tarEntry{relPath: relPath, absPath: path, info: info})
+			}
+			return nil
+		}
+		if excluded {
+			return nil
+		}
+		t.queue = append(t.queue, tarEntry{relPath: relPath, absPath: path, info: info})
 		return nil
 	})
 	if err != nil {
@@ -49,24 +187,36 @@ func (t *tarDirReader) init() (err error) {
 	return
 }
 
-func (t *tarDirReader) writeFile(f string) (err error) {
-	file, err := t.fs.Open(f)
-	if err != nil {
-		return
+func (t *tarDirReader) writeEntry(e tarEntry) (err error) {
+	var link string
+	if e.info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(e.absPath); err != nil {
+			return err
+		}
 	}
-	defer file.Close()
-	info, err := file.Stat()
+	header, err := tar.FileInfoHeader(e.info, link)
 	if err != nil {
 		return err
 	}
-	header, err := tar.FileInfoHeader(info, "")
-	if err != nil {
-		return err
+	header.Name = e.relPath
+	if e.info.IsDir() && !strings.HasSuffix(header.Name, "/") {
+		header.Name += "/"
+	}
+	if uid, gid, ok := fileOwnership(e.info); ok {
+		header.Uid = uid
+		header.Gid = gid
 	}
-	header.Name = filepath.Base(f)
 	if err = t.archive.WriteHeader(header); err != nil {
 		return
 	}
+	if !e.info.Mode().IsRegular() {
+		return t.archive.Flush()
+	}
+	file, err := os.Open(e.absPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 	if _, err = io.Copy(t.archive, file); err != nil {
 		return
 	}
@@ -74,9 +224,9 @@ func (t *tarDirReader) writeFile(f string) (err error) {
 }
 
 func (t *tarDirReader) writeQueue() {
-	for _, f := range t.queue {
-		fmt.Println("writing file to tar", f)
-		if err := t.writeFile(f); err != nil {
+	for _, e := range t.queue {
+		fmt.Println("writing file to tar", e.relPath)
+		if err := t.writeEntry(e); err != nil {
 			panic(err)
 		}
 	}
@@ -96,3 +246,115 @@ func (t *tarDirReader) Read(p []byte) (n int, err error) {
 	n, err = t.reader.Read(p)
 	return
 }
+
+// ignoreMatcher evaluates a path against an ordered list of .dockerignore
+// style patterns, including `!`-prefixed re-include rules.
+type ignoreMatcher struct {
+	patterns []ignoreRule
+}
+
+type ignoreRule struct {
+	pattern string
+	negate  bool
+}
+
+func newIgnoreMatcher(lines []string) *ignoreMatcher {
+	m := &ignoreMatcher{}
+	for _, line := range lines {
+		negate := false
+		if strings.HasPrefix(line, "!") {
+			negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(filepath.Clean(line), "/")
+		if line == "" || line == "." {
+			continue
+		}
+		m.patterns = append(m.patterns, ignoreRule{pattern: filepath.ToSlash(line), negate: negate})
+	}
+	return m
+}
+
+// Matches reports whether path should be excluded from the build context,
+// walking the patterns in order so later rules can re-include a path an
+// earlier rule excluded. A pattern that matches one of path's parent
+// directories also matches path.
+func (m *ignoreMatcher) Matches(path string) bool {
+	path = filepath.ToSlash(path)
+	parts := strings.Split(path, "/")
+	excluded := false
+	for _, rule := range m.patterns {
+		matched := matchIgnorePattern(rule.pattern, path)
+		for i := 1; i < len(parts) && !matched; i++ {
+			matched = matchIgnorePattern(rule.pattern, strings.Join(parts[:i], "/"))
+		}
+		if matched {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+// canMatchInside reports whether any negated (re-include) pattern could
+// possibly match a path somewhere below dir, which means the walk must not
+// prune dir with fs.SkipDir even though dir itself is excluded.
+func (m *ignoreMatcher) canMatchInside(dir string) bool {
+	dir = filepath.ToSlash(dir)
+	dirParts := strings.Split(dir, "/")
+	for _, rule := range m.patterns {
+		if !rule.negate {
+			continue
+		}
+		if matchIgnorePrefix(strings.Split(rule.pattern, "/"), dirParts) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchIgnorePrefix reports whether pattern could still match something
+// under the directory described by dirParts, i.e. the first len(dirParts)
+// segments of pattern are compatible with dirParts (a "**" segment always
+// is, since it can expand to match anything below it).
+func matchIgnorePrefix(pattern, dirParts []string) bool {
+	for i, part := range dirParts {
+		if i >= len(pattern) {
+			return true
+		}
+		if pattern[i] == "**" {
+			return true
+		}
+		if ok, err := filepath.Match(pattern[i], part); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchIgnorePattern matches a .dockerignore style pattern (supporting *, ?
+// and **) against a forward-slash path.
+func matchIgnorePattern(pattern, path string) bool {
+	return matchIgnoreParts(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchIgnoreParts(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		if matchIgnoreParts(pattern[1:], path) {
+			return true
+		}
+		if len(path) == 0 {
+			return false
+		}
+		return matchIgnoreParts(pattern, path[1:])
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if ok, err := filepath.Match(pattern[0], path[0]); err != nil || !ok {
+		return false
+	}
+	return matchIgnoreParts(pattern[1:], path[1:])
+}
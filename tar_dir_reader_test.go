@@ -0,0 +1,67 @@
+package godocker
+
+import "testing"
+
+func TestIgnoreMatcherMatches(t *testing.T) {
+	cases := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     bool
+	}{
+		{"simple file", []string{"secret.txt"}, "secret.txt", true},
+		{"no match", []string{"secret.txt"}, "other.txt", false},
+		{"wildcard", []string{"*.log"}, "debug.log", true},
+		{"wildcard does not cross directories", []string{"*.log"}, "logs/debug.log", false},
+		{"doublestar matches nested", []string{"**/*.log"}, "logs/debug.log", true},
+		{"doublestar matches at root", []string{"**/*.log"}, "debug.log", true},
+		{"directory name matches itself", []string{"vendor"}, "vendor", true},
+		{"later negate re-includes", []string{"*.log", "!keep.log"}, "keep.log", false},
+		{"earlier negate is overridden by a later rule", []string{"!keep.log", "*.log"}, "keep.log", true},
+		{"leading slash is stripped", []string{"/root.txt"}, "root.txt", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newIgnoreMatcher(c.patterns)
+			if got := m.Matches(c.path); got != c.want {
+				t.Errorf("Matches(%q) with patterns %v = %v, want %v", c.path, c.patterns, got, c.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreMatcherCanMatchInside(t *testing.T) {
+	cases := []struct {
+		name     string
+		patterns []string
+		dir      string
+		want     bool
+	}{
+		{"no negated patterns", []string{"vendor"}, "vendor", false},
+		{"negated pattern shares the dir's prefix", []string{"vendor", "!vendor/keep"}, "vendor", true},
+		{"negated pattern is unrelated", []string{"vendor", "!other/keep"}, "vendor", false},
+		{"doublestar negate can always match", []string{"build", "!**/keep.txt"}, "build", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newIgnoreMatcher(c.patterns)
+			if got := m.canMatchInside(c.dir); got != c.want {
+				t.Errorf("canMatchInside(%q) with patterns %v = %v, want %v", c.dir, c.patterns, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseIgnoreLines(t *testing.T) {
+	data := []byte("# comment\n\n*.log\n  trimmed.txt  \n!keep.log\n")
+	got := parseIgnoreLines(data)
+	want := []string{"*.log", "trimmed.txt", "!keep.log"}
+	if len(got) != len(want) {
+		t.Fatalf("parseIgnoreLines() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseIgnoreLines()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
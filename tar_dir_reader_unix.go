@@ -0,0 +1,18 @@
+//go:build !windows
+
+package godocker
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwnership extracts the uid/gid of info from its platform-specific
+// os.FileInfo.Sys(), reporting ok=false if it is unavailable.
+func fileOwnership(info os.FileInfo) (uid, gid int, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
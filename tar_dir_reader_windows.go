@@ -0,0 +1,11 @@
+//go:build windows
+
+package godocker
+
+import "os"
+
+// fileOwnership is a no-op on Windows, which has no uid/gid concept exposed
+// through os.FileInfo.Sys().
+func fileOwnership(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}